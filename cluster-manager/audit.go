@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxAuditLogSize is the size at which audit.log is rotated aside.
+const maxAuditLogSize = 10 * 1024 * 1024
+
+// AuditEvent is one durable record of a terminal session or cron run.
+type AuditEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Type       string    `json:"type"` // terminal.open, terminal.close, cron.run
+	Actor      string    `json:"actor,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	JobID      string    `json:"job_id,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	ExitCode   int       `json:"exit_code,omitempty"`
+	Duration   float64   `json:"duration_seconds,omitempty"`
+	BytesIn    int64     `json:"bytes_in,omitempty"`
+	BytesOut   int64     `json:"bytes_out,omitempty"`
+}
+
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+var audit *auditLog
+
+func initAudit() {
+	audit = &auditLog{path: filepath.Join(dataDir, "audit.log")}
+}
+
+func (a *auditLog) record(event AuditEvent) {
+	event.Timestamp = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("audit: failed to marshal event: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rotateIfNeeded()
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("audit: failed to open log: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// rotateIfNeeded renames audit.log aside once it crosses maxAuditLogSize.
+// Callers must hold a.mu.
+func (a *auditLog) rotateIfNeeded() {
+	info, err := os.Stat(a.path)
+	if err != nil || info.Size() < maxAuditLogSize {
+		return
+	}
+	rotated := a.path + "." + strconv.FormatInt(time.Now().Unix(), 10)
+	os.Rename(a.path, rotated)
+}
+
+// list returns events matching the given filters, any of which may be zero/empty.
+func (a *auditLog) list(from, to time.Time, actor, eventType string) []AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		if !from.IsZero() && ev.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && ev.Timestamp.After(to) {
+			continue
+		}
+		if actor != "" && ev.Actor != actor {
+			continue
+		}
+		if eventType != "" && ev.Type != eventType {
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var from, to time.Time
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	writeJSON(w, audit.list(from, to, q.Get("actor"), q.Get("type")))
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/websocket"
+)
+
+// Role is a coarse permission tier checked by requireRole.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// rank orders roles so higher tiers satisfy lower requirements.
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 3
+	case RoleOperator:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r Role) atLeast(min Role) bool {
+	return r.rank() >= min.rank()
+}
+
+func (r Role) valid() bool {
+	switch r {
+	case RoleViewer, RoleOperator, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// User is a local account stored in dataDir/users.json.
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type userStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	path  string
+}
+
+var users *userStore
+
+func initUserStore() error {
+	users = &userStore{
+		users: make(map[string]*User),
+		path:  filepath.Join(dataDir, "users.json"),
+	}
+	return users.load()
+}
+
+func (s *userStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var list []storedUser
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, su := range list {
+		u := su.User
+		u.PasswordHash = su.PasswordHash
+		s.users[u.ID] = &u
+	}
+	return nil
+}
+
+// storedUser is the on-disk form of a User; unlike the API view it keeps the
+// bcrypt password hash.
+type storedUser struct {
+	User
+	PasswordHash string `json:"password_hash"`
+}
+
+func (s *userStore) save() error {
+	list := make([]storedUser, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, storedUser{User: *u, PasswordHash: u.PasswordHash})
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+func (s *userStore) list() []*User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, u)
+	}
+	return list
+}
+
+func (s *userStore) get(id string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	return u, ok
+}
+
+func (s *userStore) getByUsername(username string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+func (s *userStore) create(u *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == u.Username {
+			return fmt.Errorf("username already exists")
+		}
+	}
+
+	u.ID = uuid.New().String()
+	u.CreatedAt = time.Now()
+	s.users[u.ID] = u
+
+	if err := s.save(); err != nil {
+		delete(s.users, u.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *userStore) delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	delete(s.users, id)
+
+	if err := s.save(); err != nil {
+		s.users[id] = existing
+		return err
+	}
+	return nil
+}
+
+// tokenTTL is how long an issued bearer token stays valid.
+const tokenTTL = 24 * time.Hour
+
+type sessionToken struct {
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// tokenStore is an in-memory, server-revocable set of issued bearer tokens.
+type tokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*sessionToken
+}
+
+var tokens = &tokenStore{tokens: make(map[string]*sessionToken)}
+
+func (t *tokenStore) issue(userID string) string {
+	token := uuid.New().String()
+	t.mu.Lock()
+	t.tokens[token] = &sessionToken{UserID: userID, ExpiresAt: time.Now().Add(tokenTTL)}
+	t.mu.Unlock()
+	return token
+}
+
+func (t *tokenStore) revoke(token string) {
+	t.mu.Lock()
+	delete(t.tokens, token)
+	t.mu.Unlock()
+}
+
+func (t *tokenStore) resolve(token string) (*User, bool) {
+	t.mu.RLock()
+	st, ok := t.tokens[token]
+	t.mu.RUnlock()
+	if !ok || time.Now().After(st.ExpiresAt) {
+		return nil, false
+	}
+	return users.get(st.UserID)
+}
+
+// actor identifies whoever is making the current request, for audit logging
+// and role checks.
+type actor struct {
+	Username string
+	Role     Role
+}
+
+type contextKey string
+
+const actorContextKey contextKey = "actor"
+
+func actorFromContext(r *http.Request) string {
+	if a, ok := r.Context().Value(actorContextKey).(actor); ok {
+		return a.Username
+	}
+	return ""
+}
+
+var secretFallbackWarnOnce sync.Once
+
+// authenticate resolves the bearer token on an HTTP request, falling back to
+// the shared secret (treated as admin) for backward compatibility.
+func authenticate(r *http.Request) (actor, bool) {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if user, ok := tokens.resolve(token); ok {
+			return actor{Username: user.Username, Role: user.Role}, true
+		}
+	}
+
+	if sharedSecret != "" && r.Header.Get("X-Cluster-Manager-Secret") == sharedSecret {
+		secretFallbackWarnOnce.Do(func() {
+			log.Println("WARNING: request authenticated via CLUSTER_MANAGER_SECRET fallback; this grants admin access and is deprecated, migrate to per-user tokens")
+		})
+		return actor{Username: "shared-secret", Role: RoleAdmin}, true
+	}
+
+	return actor{}, false
+}
+
+// authenticateWS resolves the bearer token for a /terminal WebSocket, where
+// browsers can't set an Authorization header: a negotiated
+// "bearer.<token>" subprotocol or a ?token= query param are accepted.
+func authenticateWS(ws *websocket.Conn) (actor, bool) {
+	req := ws.Request()
+
+	if proto := req.Header.Get("Sec-WebSocket-Protocol"); proto != "" {
+		for _, p := range strings.Split(proto, ",") {
+			p = strings.TrimSpace(p)
+			if token, ok := strings.CutPrefix(p, "bearer."); ok {
+				if user, ok := tokens.resolve(token); ok {
+					return actor{Username: user.Username, Role: user.Role}, true
+				}
+			}
+		}
+	}
+
+	if token := req.URL.Query().Get("token"); token != "" {
+		if user, ok := tokens.resolve(token); ok {
+			return actor{Username: user.Username, Role: user.Role}, true
+		}
+	}
+
+	if sharedSecret != "" {
+		provided := req.Header.Get("X-Cluster-Manager-Secret")
+		if provided == "" {
+			provided = req.URL.Query().Get("secret")
+		}
+		if provided == sharedSecret {
+			secretFallbackWarnOnce.Do(func() {
+				log.Println("WARNING: request authenticated via CLUSTER_MANAGER_SECRET fallback; this grants admin access and is deprecated, migrate to per-user tokens")
+			})
+			return actor{Username: "shared-secret", Role: RoleAdmin}, true
+		}
+	}
+
+	return actor{}, false
+}
+
+// requireRole authenticates the request and rejects it unless the resolved
+// actor's role is at least min.
+func requireRole(min Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := authenticate(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !a.Role.atLeast(min) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), actorContextKey, a)))
+	}
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := users.getByUsername(req.Username)
+	if !ok {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, loginResponse{Token: tokens.issue(user.ID), Role: user.Role})
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if token := strings.TrimPrefix(authHeader, "Bearer "); token != "" {
+		tokens.revoke(token)
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+func handleUsersList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, users.list())
+}
+
+type createUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+func handleUsersCreate(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		http.Error(w, "username and password required", http.StatusBadRequest)
+		return
+	}
+	if !req.Role.valid() {
+		http.Error(w, "role must be viewer, operator, or admin", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	user := &User{Username: req.Username, PasswordHash: string(hash), Role: req.Role}
+	if err := users.create(user); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, user)
+}
+
+func handleUsersDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := users.delete(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
@@ -14,14 +13,20 @@ import (
 )
 
 type CronJob struct {
-	ID         string       `json:"id"`
-	Name       string       `json:"name"`
-	Schedule   CronSchedule `json:"schedule"`
-	Command    string       `json:"command"`
-	Enabled    bool         `json:"enabled"`
-	LastRun    *time.Time   `json:"last_run,omitempty"`
-	LastStatus string       `json:"last_status,omitempty"`
-	CreatedAt  time.Time    `json:"created_at"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Schedule      CronSchedule      `json:"schedule"`
+	Command       string            `json:"command"`
+	Enabled       bool              `json:"enabled"`
+	Timeout       time.Duration     `json:"timeout,omitempty"`
+	MaxConcurrent int               `json:"max_concurrent,omitempty"` // default 1; extra runs are skipped
+	Env           map[string]string `json:"env,omitempty"`
+	ClusterScope  string            `json:"cluster_scope,omitempty"` // "", "local", "any-one-node", "all-nodes", or "label:<selector>"
+	NodeSelector  string            `json:"node_selector,omitempty"` // "key=value,..." matched against a node's labels
+	Source        string            `json:"source,omitempty"`        // "" for locally-owned jobs, "k8s" for jobs mirrored from a batch/v1.CronJob
+	LastRun       *time.Time        `json:"last_run,omitempty"`
+	LastStatus    string            `json:"last_status,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
 }
 
 type CronSchedule struct {
@@ -123,7 +128,7 @@ func (s *cronStore) create(job *CronJob) error {
 	}
 
 	if job.Enabled {
-		syncCrontab()
+		registerIfOwnedLocally(job)
 	}
 	return nil
 }
@@ -148,10 +153,26 @@ func (s *cronStore) update(id string, job *CronJob) error {
 		return err
 	}
 
-	syncCrontab()
+	if job.Enabled {
+		registerIfOwnedLocally(job)
+	} else {
+		runner.unregister(id)
+	}
 	return nil
 }
 
+// registerIfOwnedLocally schedules job on this node only if it isn't
+// cluster-scoped, or it is and this node is the one responsible for it.
+// Cluster-scoped jobs this node does not own are left to pushAssignments/
+// syncAssigned, which also keeps the decision current as peers come and go.
+func registerIfOwnedLocally(job *CronJob) {
+	if clusterScoped(job) && pm != nil && !pm.shouldRun(job, pm.allNodes()) {
+		runner.unregister(job.ID)
+		return
+	}
+	runner.register(job)
+}
+
 func (s *cronStore) delete(id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -168,7 +189,7 @@ func (s *cronStore) delete(id string) error {
 		return err
 	}
 
-	syncCrontab()
+	runner.unregister(id)
 	return nil
 }
 
@@ -184,6 +205,20 @@ func (s *cronStore) updateRunStatus(id string, status string) {
 	}
 }
 
+// upsertMirrored stores or refreshes a read-only job mirrored from an
+// external source (e.g. Kubernetes), preserving locally-tracked run state.
+func (s *cronStore) upsertMirrored(job *CronJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.jobs[job.ID]; ok {
+		job.LastRun = existing.LastRun
+		job.LastStatus = existing.LastStatus
+	}
+	s.jobs[job.ID] = job
+	s.save()
+}
+
 func getCronCount() int {
 	if store == nil {
 		return 0
@@ -215,6 +250,21 @@ func handleCronCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("target") == "k8s" {
+		if k8sInt == nil {
+			http.Error(w, "kubernetes integration not enabled", http.StatusBadRequest)
+			return
+		}
+		mirrored, err := k8sInt.createCronJob(&job)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, mirrored)
+		return
+	}
+
 	if err := store.create(&job); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -231,6 +281,11 @@ func handleCronUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if existing, ok := store.get(id); ok && existing.Source == "k8s" {
+		http.Error(w, "job is mirrored from kubernetes and is read-only", http.StatusConflict)
+		return
+	}
+
 	var job CronJob
 	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
@@ -267,6 +322,11 @@ func handleCronDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if existing, ok := store.get(id); ok && existing.Source == "k8s" {
+		http.Error(w, "job is mirrored from kubernetes and is read-only", http.StatusConflict)
+		return
+	}
+
 	if err := store.delete(id); err != nil {
 		if err.Error() == "job not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -286,22 +346,30 @@ func handleCronRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job, ok := store.get(id)
+	// Use runner.lookup, not store.get: on a non-leader node, cluster-scoped
+	// jobs it is actively running only exist in runner.assigned, never in the
+	// local store (creates/updates are proxied to the leader).
+	job, ok := runner.lookup(id)
 	if !ok {
 		http.Error(w, "job not found", http.StatusNotFound)
 		return
 	}
 
-	// Run the command asynchronously
-	go func() {
-		cmd := exec.Command("chroot", hostRoot, "/bin/sh", "-c", job.Command)
-		err := cmd.Run()
-		status := "success"
+	if job.Source == "k8s" {
+		if k8sInt == nil {
+			http.Error(w, "kubernetes integration not enabled", http.StatusBadGateway)
+			return
+		}
+		runID, err := k8sInt.triggerOneShot(job, actorFromContext(r))
 		if err != nil {
-			status = "failed: " + err.Error()
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
 		}
-		store.updateRunStatus(id, status)
-	}()
+		writeJSON(w, map[string]string{"status": "started", "run_id": runID})
+		return
+	}
+
+	go runner.runJob(id, actorFromContext(r))
 
 	writeJSON(w, map[string]string{"status": "started"})
 }
@@ -325,43 +393,10 @@ func validateSchedule(s *CronSchedule) error {
 	if s.Weekday == "" {
 		s.Weekday = "*"
 	}
-	return nil
-}
-
-// syncCrontab syncs enabled cron jobs to the host's crontab
-func syncCrontab() {
-	store.mu.RLock()
-	defer store.mu.RUnlock()
 
-	var lines []string
-	lines = append(lines, "# Managed by cluster-manager - DO NOT EDIT")
-
-	for _, job := range store.jobs {
-		if !job.Enabled {
-			continue
-		}
-		// Standard cron format: min hour day month weekday command
-		line := fmt.Sprintf("%s %s %s %s %s %s # cluster-manager:%s",
-			job.Schedule.Minute,
-			job.Schedule.Hour,
-			job.Schedule.Day,
-			job.Schedule.Month,
-			job.Schedule.Weekday,
-			job.Command,
-			job.ID,
-		)
-		lines = append(lines, line)
-	}
-
-	crontabPath := filepath.Join(dataDir, "crontab")
-	content := ""
-	for _, line := range lines {
-		content += line + "\n"
-	}
-	os.WriteFile(crontabPath, []byte(content), 0644)
-
-	// Install to host crontab
-	hostCrontab := filepath.Join(hostRoot, "var/spool/cron/crontabs/root")
-	os.MkdirAll(filepath.Dir(hostCrontab), 0755)
-	os.WriteFile(hostCrontab, []byte(content), 0600)
+	spec := fmt.Sprintf("%s %s %s %s %s %s", s.Second, s.Minute, s.Hour, s.Day, s.Month, s.Weekday)
+	if _, err := scheduleParser.Parse(spec); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return nil
 }
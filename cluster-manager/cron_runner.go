@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// maxKeptRuns is the number of run records (and log files) retained per job.
+const maxKeptRuns = 20
+
+// scheduleParser matches the field layout cron.New(cron.WithSeconds()) uses,
+// so validateSchedule rejects anything register() would otherwise silently
+// fail to schedule.
+var scheduleParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// CronRun is a single execution of a CronJob.
+type CronRun struct {
+	ID         string     `json:"id"`
+	JobID      string     `json:"job_id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   int        `json:"exit_code"`
+	Status     string     `json:"status"` // running, success, failed, timeout, killed
+	LogSize    int64      `json:"log_size"`
+}
+
+type activeRun struct {
+	jobID  string
+	cancel context.CancelFunc
+}
+
+// cronRunner owns the in-process schedule and executes jobs directly,
+// replacing the old host-crontab sync.
+type cronRunner struct {
+	mu      sync.Mutex
+	c        *cron.Cron
+	entries  map[string]cron.EntryID // jobID -> scheduled entry
+	running  map[string]int          // jobID -> in-flight run count
+	active   map[string]*activeRun   // runID -> cancel handle
+	runs     map[string][]*CronRun   // jobID -> run history, oldest first
+	assigned map[string]*CronJob     // jobID -> job definitions pushed by a cluster leader
+}
+
+var runner *cronRunner
+
+func initCronRunner() {
+	runner = &cronRunner{
+		c:        cron.New(cron.WithSeconds()),
+		entries:  make(map[string]cron.EntryID),
+		running:  make(map[string]int),
+		active:   make(map[string]*activeRun),
+		runs:     make(map[string][]*CronRun),
+		assigned: make(map[string]*CronJob),
+	}
+	runner.c.Start()
+
+	for _, job := range store.list() {
+		runner.loadRuns(job.ID)
+		if job.Enabled && job.Source != "k8s" {
+			runner.register(job)
+		}
+	}
+}
+
+// register (re)installs the schedule entry for job, replacing any existing one.
+func (r *cronRunner) register(job *CronJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.entries[job.ID]; ok {
+		r.c.Remove(id)
+		delete(r.entries, job.ID)
+	}
+
+	spec := fmt.Sprintf("%s %s %s %s %s %s",
+		job.Schedule.Second, job.Schedule.Minute, job.Schedule.Hour,
+		job.Schedule.Day, job.Schedule.Month, job.Schedule.Weekday)
+
+	jobID := job.ID
+	id, err := r.c.AddFunc(spec, func() { r.runJob(jobID, "") })
+	if err != nil {
+		log.Printf("cron: failed to schedule job %s: %v", job.ID, err)
+		return
+	}
+	r.entries[job.ID] = id
+}
+
+// unregister removes the schedule entry for jobID and cancels any in-flight runs.
+func (r *cronRunner) unregister(jobID string) {
+	r.mu.Lock()
+	if id, ok := r.entries[jobID]; ok {
+		r.c.Remove(id)
+		delete(r.entries, jobID)
+	}
+	var toCancel []context.CancelFunc
+	for _, ar := range r.active {
+		if ar.jobID == jobID {
+			toCancel = append(toCancel, ar.cancel)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, cancel := range toCancel {
+		cancel()
+	}
+}
+
+// lookup finds a job's current definition, checking locally-owned jobs first
+// and falling back to jobs assigned to this node by a cluster leader.
+func (r *cronRunner) lookup(jobID string) (*CronJob, bool) {
+	if job, ok := store.get(jobID); ok {
+		return job, true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.assigned[jobID]
+	return job, ok
+}
+
+// syncAssigned replaces the set of cluster-assigned jobs scheduled on this
+// node with jobs, registering new/changed entries and unregistering any that
+// are no longer assigned.
+func (r *cronRunner) syncAssigned(jobs []*CronJob) {
+	r.mu.Lock()
+	newAssigned := make(map[string]*CronJob, len(jobs))
+	for _, job := range jobs {
+		newAssigned[job.ID] = job
+	}
+	old := r.assigned
+	r.assigned = newAssigned
+	r.mu.Unlock()
+
+	for id := range old {
+		if _, ok := newAssigned[id]; !ok {
+			r.unregister(id)
+		}
+	}
+	for _, job := range jobs {
+		r.register(job)
+	}
+}
+
+// runJob executes one run of jobID, honoring MaxConcurrent and Timeout.
+// actorName is the user who triggered a manual run, or "" for scheduled runs.
+func (r *cronRunner) runJob(jobID, actorName string) {
+	job, ok := r.lookup(jobID)
+	if !ok {
+		return
+	}
+
+	maxConcurrent := job.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	r.mu.Lock()
+	if r.running[jobID] >= maxConcurrent {
+		r.mu.Unlock()
+		log.Printf("cron: skipping run for job %s, %d already in flight", jobID, maxConcurrent)
+		return
+	}
+	r.running[jobID]++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.running[jobID]--
+		r.mu.Unlock()
+	}()
+
+	run := &CronRun{
+		ID:        uuid.New().String(),
+		JobID:     jobID,
+		StartedAt: time.Now(),
+		Status:    "running",
+	}
+	r.addRun(run)
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	r.mu.Lock()
+	r.active[run.ID] = &activeRun{jobID: jobID, cancel: cancel}
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.active, run.ID)
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	logPath := r.logPath(jobID, run.ID)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		log.Printf("cron: failed to create log dir for job %s: %v", jobID, err)
+	}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		log.Printf("cron: failed to create log file for job %s: %v", jobID, err)
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+
+	cmd := exec.CommandContext(ctx, "chroot", hostRoot, "/bin/sh", "-c", job.Command)
+	cmd.Env = os.Environ()
+	for k, v := range job.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if logFile != nil {
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+	}
+
+	runErr := cmd.Run()
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if logFile != nil {
+		if info, statErr := logFile.Stat(); statErr == nil {
+			run.LogSize = info.Size()
+		}
+	}
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		run.Status = "timeout"
+		run.ExitCode = -1
+	case ctx.Err() == context.Canceled:
+		run.Status = "killed"
+		run.ExitCode = -1
+	case runErr != nil:
+		run.Status = "failed"
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			run.ExitCode = exitErr.ExitCode()
+		} else {
+			run.ExitCode = -1
+		}
+	default:
+		run.Status = "success"
+		run.ExitCode = 0
+	}
+	r.finishRun(run)
+
+	statusMsg := run.Status
+	if run.Status == "failed" {
+		statusMsg = fmt.Sprintf("failed: exit %d", run.ExitCode)
+	}
+	if _, ok := store.get(jobID); ok {
+		store.updateRunStatus(jobID, statusMsg)
+	}
+
+	duration := finished.Sub(run.StartedAt).Seconds()
+	cronRunsTotal.WithLabelValues(jobID, run.Status).Inc()
+	cronLastDurationSeconds.WithLabelValues(jobID).Set(duration)
+	if run.Status == "success" {
+		cronLastSuccessTimestamp.WithLabelValues(jobID).Set(float64(finished.Unix()))
+	}
+	audit.record(AuditEvent{
+		Type:     "cron.run",
+		Actor:    actorName,
+		JobID:    jobID,
+		Command:  job.Command,
+		ExitCode: run.ExitCode,
+		Duration: duration,
+	})
+
+	r.rotateLogs(jobID)
+}
+
+// kill cancels the in-flight run identified by runID, if any.
+func (r *cronRunner) kill(runID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ar, ok := r.active[runID]
+	if !ok {
+		return false
+	}
+	ar.cancel()
+	return true
+}
+
+func (r *cronRunner) isActive(runID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.active[runID]
+	return ok
+}
+
+func (r *cronRunner) listRuns(jobID string) []*CronRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*CronRun(nil), r.runs[jobID]...)
+}
+
+func (r *cronRunner) findRun(jobID, runID string) *CronRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs[jobID] {
+		if run.ID == runID {
+			return run
+		}
+	}
+	return nil
+}
+
+func (r *cronRunner) logPath(jobID, runID string) string {
+	return filepath.Join(dataDir, "logs", jobID, runID+".log")
+}
+
+func (r *cronRunner) addRun(run *CronRun) {
+	r.mu.Lock()
+	r.runs[run.JobID] = append(r.runs[run.JobID], run)
+	if len(r.runs[run.JobID]) > maxKeptRuns {
+		r.runs[run.JobID] = r.runs[run.JobID][len(r.runs[run.JobID])-maxKeptRuns:]
+	}
+	r.mu.Unlock()
+	r.persistRuns(run.JobID)
+}
+
+func (r *cronRunner) finishRun(run *CronRun) {
+	r.persistRuns(run.JobID)
+}
+
+// finishRunFields locks in the terminal status of a run by ID rather than
+// handing out the *CronRun for a caller to mutate directly, since runs are
+// also read concurrently by listRuns/findRun from HTTP handlers.
+func (r *cronRunner) finishRunFields(jobID, runID, status string, exitCode int, finishedAt time.Time) {
+	r.mu.Lock()
+	for _, run := range r.runs[jobID] {
+		if run.ID == runID {
+			run.Status = status
+			run.ExitCode = exitCode
+			run.FinishedAt = &finishedAt
+			break
+		}
+	}
+	r.mu.Unlock()
+	r.persistRuns(jobID)
+}
+
+func (r *cronRunner) runsFile(jobID string) string {
+	return filepath.Join(dataDir, "runs", jobID+".json")
+}
+
+func (r *cronRunner) persistRuns(jobID string) {
+	r.mu.Lock()
+	runs := append([]*CronRun(nil), r.runs[jobID]...)
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(dataDir, "runs"), 0755); err != nil {
+		log.Printf("cron: failed to create runs dir: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(r.runsFile(jobID), data, 0644); err != nil {
+		log.Printf("cron: failed to persist runs for job %s: %v", jobID, err)
+	}
+}
+
+func (r *cronRunner) loadRuns(jobID string) {
+	data, err := os.ReadFile(r.runsFile(jobID))
+	if err != nil {
+		return
+	}
+	var runs []*CronRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return
+	}
+	r.mu.Lock()
+	r.runs[jobID] = runs
+	r.mu.Unlock()
+}
+
+// rotateLogs deletes log files beyond the newest maxKeptRuns for jobID.
+func (r *cronRunner) rotateLogs(jobID string) {
+	dir := filepath.Join(dataDir, "logs", jobID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		name    string
+		modTime time.Time
+	}
+	files := make([]logFile, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{name: e.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	if len(files) <= maxKeptRuns {
+		return
+	}
+	for _, f := range files[:len(files)-maxKeptRuns] {
+		os.Remove(filepath.Join(dir, f.name))
+	}
+}
+
+func handleCronRuns(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if _, ok := runner.lookup(jobID); !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, runner.listRuns(jobID))
+}
+
+func handleCronRunLog(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	runID := r.PathValue("runID")
+
+	if job, ok := runner.lookup(jobID); ok && job.Source == "k8s" {
+		if k8sInt == nil {
+			http.Error(w, "kubernetes integration not enabled", http.StatusBadGateway)
+			return
+		}
+		k8sInt.streamJobLog(w, r, job, runID)
+		return
+	}
+
+	if runner.findRun(jobID, runID) == nil {
+		http.Error(w, "run not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(runner.logPath(jobID, runID))
+	if err != nil {
+		http.Error(w, "log not available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if r.URL.Query().Get("follow") != "1" {
+		io.Copy(w, f)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		io.Copy(w, f)
+		return
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			flusher.Flush()
+		}
+		if err != nil {
+			if err != io.EOF {
+				return
+			}
+			if !runner.isActive(runID) {
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+	}
+}
+
+func handleCronRunKill(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	runID := r.PathValue("runID")
+
+	if runner.findRun(jobID, runID) == nil {
+		http.Error(w, "run not found", http.StatusNotFound)
+		return
+	}
+	if !runner.kill(runID) {
+		http.Error(w, "run not active", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "killed"})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// k8sMirrorPrefix marks CronJob IDs that mirror a real batch/v1.CronJob
+// resource rather than a locally-owned job.
+const k8sMirrorPrefix = "k8s:"
+
+const k8sPollInterval = 30 * time.Second
+
+// k8sIntegration mirrors batch/v1.CronJob resources into the local cronStore
+// as read-only entries, and lets handleCronCreate/handleCronRun target the
+// cluster instead of the local chroot when a job's Source is "k8s".
+type k8sIntegration struct {
+	clientset        *kubernetes.Clientset
+	namespaces       []string
+	defaultNamespace string
+}
+
+var k8sInt *k8sIntegration
+
+func initK8s(enabled bool, namespacesCSV, defaultNamespace string) {
+	if !enabled {
+		return
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.Printf("k8s: disabling integration, failed to load in-cluster config: %v", err)
+		return
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Printf("k8s: disabling integration, failed to build clientset: %v", err)
+		return
+	}
+
+	var namespaces []string
+	for _, ns := range strings.Split(namespacesCSV, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{"default"}
+	}
+	if defaultNamespace == "" {
+		defaultNamespace = namespaces[0]
+	}
+
+	k8sInt = &k8sIntegration{clientset: clientset, namespaces: namespaces, defaultNamespace: defaultNamespace}
+	go k8sInt.mirrorLoop()
+}
+
+func mirrorJobID(namespace, name string) string {
+	return fmt.Sprintf("%s%s/%s", k8sMirrorPrefix, namespace, name)
+}
+
+func splitMirrorID(id string) (namespace, name string, err error) {
+	rest, ok := strings.CutPrefix(id, k8sMirrorPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("not a kubernetes-sourced job: %s", id)
+	}
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed kubernetes job id: %s", id)
+	}
+	return parts[0], parts[1], nil
+}
+
+func (k *k8sIntegration) mirrorLoop() {
+	k.syncOnce()
+	ticker := time.NewTicker(k8sPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		k.syncOnce()
+	}
+}
+
+func (k *k8sIntegration) syncOnce() {
+	for _, ns := range k.namespaces {
+		list, err := k.clientset.BatchV1().CronJobs(ns).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			log.Printf("k8s: failed to list CronJobs in %s: %v", ns, err)
+			continue
+		}
+		for i := range list.Items {
+			store.upsertMirrored(mirroredJob(&list.Items[i]))
+		}
+	}
+}
+
+// mirroredJob converts a batch/v1.CronJob into our read-only CronJob view.
+func mirroredJob(cj *batchv1.CronJob) *CronJob {
+	var command string
+	if containers := cj.Spec.JobTemplate.Spec.Template.Spec.Containers; len(containers) > 0 {
+		command = strings.Join(append([]string{containers[0].Image}, containers[0].Command...), " ")
+	}
+
+	return &CronJob{
+		ID:        mirrorJobID(cj.Namespace, cj.Name),
+		Name:      cj.Namespace + "/" + cj.Name,
+		Schedule:  parseK8sSchedule(cj.Spec.Schedule),
+		Command:   command,
+		Enabled:   cj.Spec.Suspend == nil || !*cj.Spec.Suspend,
+		Source:    "k8s",
+		CreatedAt: cj.CreationTimestamp.Time,
+	}
+}
+
+// parseK8sSchedule adapts a standard 5-field k8s cron schedule into our
+// 6-field CronSchedule, always firing at second 0.
+func parseK8sSchedule(spec string) CronSchedule {
+	sched := CronSchedule{Second: "0", Minute: "*", Hour: "*", Day: "*", Month: "*", Weekday: "*"}
+	fields := strings.Fields(spec)
+	if len(fields) == 5 {
+		sched.Minute, sched.Hour, sched.Day, sched.Month, sched.Weekday = fields[0], fields[1], fields[2], fields[3], fields[4]
+	}
+	return sched
+}
+
+// createCronJob creates a real batch/v1.CronJob for a POST /cron?target=k8s
+// request instead of a locally-owned job; the created resource is mirrored
+// into the local store as a read-only entry right away.
+func (k *k8sIntegration) createCronJob(job *CronJob) (*CronJob, error) {
+	schedule := fmt.Sprintf("%s %s %s %s %s",
+		job.Schedule.Minute, job.Schedule.Hour, job.Schedule.Day, job.Schedule.Month, job.Schedule.Weekday)
+
+	cj := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: job.Name, Namespace: k.defaultNamespace},
+		Spec: batchv1.CronJobSpec{
+			Schedule: schedule,
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							RestartPolicy: corev1.RestartPolicyOnFailure,
+							Containers: []corev1.Container{{
+								Name:    job.Name,
+								Image:   "busybox",
+								Command: []string{"/bin/sh", "-c", job.Command},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	created, err := k.clientset.BatchV1().CronJobs(k.defaultNamespace).Create(context.Background(), cj, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	mirrored := mirroredJob(created)
+	store.upsertMirrored(mirrored)
+	return mirrored, nil
+}
+
+// triggerOneShot creates a one-shot batch/v1.Job from a mirrored CronJob's
+// template, for a manual POST /cron/{id}/run.
+func (k *k8sIntegration) triggerOneShot(job *CronJob, actorName string) (string, error) {
+	namespace, name, err := splitMirrorID(job.ID)
+	if err != nil {
+		return "", err
+	}
+
+	cj, err := k.clientset.BatchV1().CronJobs(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	runName := fmt.Sprintf("%s-manual-%d", name, time.Now().UnixNano())
+	oneShot := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: runName, Namespace: namespace},
+		Spec:       cj.Spec.JobTemplate.Spec,
+	}
+	if _, err := k.clientset.BatchV1().Jobs(namespace).Create(context.Background(), oneShot, metav1.CreateOptions{}); err != nil {
+		return "", err
+	}
+
+	runner.addRun(&CronRun{ID: runName, JobID: job.ID, StartedAt: time.Now(), Status: "running"})
+	go k.watchOneShot(namespace, runName, job, actorName)
+
+	return runName, nil
+}
+
+// watchOneShot polls a one-shot Job until it finishes and records the result
+// on the matching CronRun, mirroring the audit/metrics bookkeeping runJob
+// does for locally-executed runs.
+func (k *k8sIntegration) watchOneShot(namespace, jobName string, job *CronJob, actorName string) {
+	started := time.Now()
+	for {
+		time.Sleep(3 * time.Second)
+
+		j, err := k.clientset.BatchV1().Jobs(namespace).Get(context.Background(), jobName, metav1.GetOptions{})
+		if err != nil {
+			return
+		}
+		if j.Status.Succeeded == 0 && j.Status.Failed == 0 {
+			continue
+		}
+
+		finished := time.Now()
+		status, exitCode := "success", 0
+		if j.Status.Succeeded == 0 {
+			status, exitCode = "failed", -1
+		}
+		runner.finishRunFields(job.ID, jobName, status, exitCode, finished)
+
+		duration := finished.Sub(started).Seconds()
+		cronRunsTotal.WithLabelValues(job.ID, status).Inc()
+		cronLastDurationSeconds.WithLabelValues(job.ID).Set(duration)
+		if status == "success" {
+			cronLastSuccessTimestamp.WithLabelValues(job.ID).Set(float64(finished.Unix()))
+		}
+		audit.record(AuditEvent{
+			Type:     "cron.run",
+			Actor:    actorName,
+			JobID:    job.ID,
+			Command:  job.Command,
+			ExitCode: exitCode,
+			Duration: duration,
+		})
+		return
+	}
+}
+
+// streamJobLog serves GET /cron/{id}/runs/{runID}/log for a k8s-sourced job
+// by tailing the log of the pod backing runID (a one-shot Job name).
+func (k *k8sIntegration) streamJobLog(w http.ResponseWriter, r *http.Request, job *CronJob, runID string) {
+	namespace, _, err := splitMirrorID(job.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pods, err := k.clientset.CoreV1().Pods(namespace).List(r.Context(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", runID),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		http.Error(w, "pod not found for run", http.StatusNotFound)
+		return
+	}
+
+	follow := r.URL.Query().Get("follow") == "1"
+	logReq := k.clientset.CoreV1().Pods(namespace).GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{Follow: follow})
+	stream, err := logReq.Stream(r.Context())
+	if err != nil {
+		http.Error(w, "failed to open pod log stream: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	flusher, canFlush := w.(http.Flusher)
+	if !follow || !canFlush {
+		io.Copy(w, stream)
+		return
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			flusher.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -30,6 +30,12 @@ func main() {
 	addr := flag.String("addr", ":9090", "HTTP listen address")
 	flag.StringVar(&dataDir, "data-dir", "/var/lib/cluster-manager", "Data directory for cron storage")
 	flag.StringVar(&hostRoot, "host-root", "/host", "Host filesystem root mount")
+	peers := flag.String("peers", "", "comma-separated addresses (host:port) of sibling cluster-manager instances")
+	selfAddr := flag.String("self-addr", "", "address other cluster-manager instances can use to reach this node; defaults to the hostname")
+	nodeLabels := flag.String("node-labels", "", "comma-separated key=value labels for this node, matched against cron NodeSelector")
+	k8sEnabled := flag.Bool("k8s-enabled", false, "mirror batch/v1.CronJob resources into the cron store using the in-cluster kubeconfig")
+	k8sNamespaces := flag.String("k8s-namespaces", "default", "comma-separated namespaces to watch for CronJob resources")
+	k8sDefaultNamespace := flag.String("k8s-default-namespace", "", "namespace used for cron jobs created with ?target=k8s; defaults to the first of --k8s-namespaces")
 	flag.Parse()
 
 	sharedSecret = os.Getenv("CLUSTER_MANAGER_SECRET")
@@ -40,15 +46,36 @@ func main() {
 	if err := initCronStorage(); err != nil {
 		log.Fatalf("failed to init cron storage: %v", err)
 	}
+	// initAudit must run before initCronRunner: loaded jobs can fire
+	// immediately (second-granularity schedules) and runJob unconditionally
+	// calls audit.record.
+	initAudit()
+	initCronRunner()
+	initPeers(*peers, *selfAddr, *nodeLabels)
+	initK8s(*k8sEnabled, *k8sNamespaces, *k8sDefaultNamespace)
+	if err := initUserStore(); err != nil {
+		log.Fatalf("failed to init user store: %v", err)
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /healthz", handleHealthz)
-	mux.HandleFunc("GET /info", authMiddleware(handleInfo))
-	mux.HandleFunc("GET /cron", authMiddleware(handleCronList))
-	mux.HandleFunc("POST /cron", authMiddleware(handleCronCreate))
-	mux.HandleFunc("PUT /cron/{id}", authMiddleware(handleCronUpdate))
-	mux.HandleFunc("DELETE /cron/{id}", authMiddleware(handleCronDelete))
-	mux.HandleFunc("POST /cron/{id}/run", authMiddleware(handleCronRun))
+	mux.Handle("GET /metrics", metricsHandler())
+	mux.HandleFunc("POST /auth/login", handleLogin)
+	mux.HandleFunc("POST /auth/logout", requireRole(RoleViewer, handleLogout))
+	mux.HandleFunc("GET /info", requireRole(RoleViewer, handleInfo))
+	mux.HandleFunc("GET /audit", requireRole(RoleAdmin, handleAudit))
+	mux.HandleFunc("GET /cron", requireRole(RoleViewer, handleCronList))
+	mux.HandleFunc("POST /cron", requireRole(RoleOperator, forwardToLeaderIfNeeded(handleCronCreate)))
+	mux.HandleFunc("PUT /cron/{id}", requireRole(RoleOperator, forwardToLeaderIfNeeded(handleCronUpdate)))
+	mux.HandleFunc("DELETE /cron/{id}", requireRole(RoleOperator, forwardToLeaderIfNeeded(handleCronDelete)))
+	mux.HandleFunc("POST /cron/{id}/run", requireRole(RoleOperator, handleCronRun))
+	mux.HandleFunc("GET /cron/{id}/runs", requireRole(RoleViewer, handleCronRuns))
+	mux.HandleFunc("GET /cron/{id}/runs/{runID}/log", requireRole(RoleViewer, handleCronRunLog))
+	mux.HandleFunc("POST /cron/{id}/runs/{runID}/kill", requireRole(RoleOperator, handleCronRunKill))
+	mux.HandleFunc("GET /users", requireRole(RoleAdmin, handleUsersList))
+	mux.HandleFunc("POST /users", requireRole(RoleAdmin, handleUsersCreate))
+	mux.HandleFunc("DELETE /users/{id}", requireRole(RoleAdmin, handleUsersDelete))
+	mux.HandleFunc("POST /internal/assign", handleInternalAssign)
 	mux.Handle("/terminal", websocket.Handler(handleTerminal))
 
 	log.Printf("cluster-manager listening on %s", *addr)
@@ -100,27 +127,11 @@ func intToStr(n int) string {
 	return strings.TrimPrefix(strings.TrimPrefix(string(rune('0'+n/10))+string(rune('0'+n%10)), "0"), "")
 }
 
-func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if sharedSecret != "" {
-			provided := r.Header.Get("X-Cluster-Manager-Secret")
-			if provided != sharedSecret {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
-				return
-			}
-		}
-		next(w, r)
-	}
-}
-
 func handleTerminal(ws *websocket.Conn) {
-	// Verify auth for WebSocket
-	if sharedSecret != "" {
-		provided := ws.Request().Header.Get("X-Cluster-Manager-Secret")
-		if provided != sharedSecret {
-			ws.Close()
-			return
-		}
+	a, ok := authenticateWS(ws)
+	if !ok || !a.Role.atLeast(RoleAdmin) {
+		ws.Close()
+		return
 	}
 
 	shell := os.Getenv("SHELL")
@@ -136,7 +147,24 @@ func handleTerminal(ws *websocket.Conn) {
 		"USER=root",
 	)
 
-	runTerminalSession(ws, cmd)
+	remoteAddr := ws.Request().RemoteAddr
+	started := time.Now()
+	terminalSessionsActive.Inc()
+	log.Printf("terminal session opened by %s from %s (protocol %s)", a.Username, remoteAddr, negotiatedProtocolVersion(ws))
+	audit.record(AuditEvent{Type: "terminal.open", Actor: a.Username, RemoteAddr: remoteAddr})
+
+	result := runTerminalSession(ws, cmd)
+
+	terminalSessionsActive.Dec()
+	audit.record(AuditEvent{
+		Type:       "terminal.close",
+		Actor:      a.Username,
+		RemoteAddr: remoteAddr,
+		ExitCode:   result.ExitCode,
+		BytesIn:    result.BytesIn,
+		BytesOut:   result.BytesOut,
+		Duration:   time.Since(started).Seconds(),
+	})
 }
 
 func writeJSON(w http.ResponseWriter, payload any) {
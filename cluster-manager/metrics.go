@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	cronRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_runs_total",
+		Help: "Total number of cron job runs, by final status.",
+	}, []string{"id", "status"})
+
+	cronLastDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_last_duration_seconds",
+		Help: "Duration of the most recent run of a cron job, in seconds.",
+	}, []string{"id"})
+
+	cronLastSuccessTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cronjob_last_success_timestamp",
+		Help: "Unix timestamp of the most recent successful run of a cron job.",
+	}, []string{"id"})
+
+	terminalSessionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "terminal_sessions_active",
+		Help: "Number of currently open /terminal WebSocket sessions.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cronRunsTotal, cronLastDurationSeconds, cronLastSuccessTimestamp, terminalSessionsActive)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	leaseDuration   = 15 * time.Second
+	leaseRenewEvery = 5 * time.Second
+)
+
+// leaseRecord is the content of the shared lease file used for leader election.
+type leaseRecord struct {
+	HolderID  string    `json:"holder_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// assignPayload is what the leader pushes to /internal/assign on each peer.
+type assignPayload struct {
+	Jobs  []*CronJob `json:"jobs"`
+	Nodes []string   `json:"nodes"` // all known node IDs, in a stable order
+}
+
+// peerManager discovers sibling cluster-manager instances, elects a leader
+// via a lease file on the shared data directory, and distributes
+// cluster-scoped cron jobs to the nodes that should run them.
+type peerManager struct {
+	mu         sync.RWMutex
+	selfID     string
+	peers      []string
+	labels     map[string]string
+	leasePath  string
+	isLeader   bool
+	leaderAddr string
+}
+
+var pm *peerManager
+
+func initPeers(peersCSV, selfAddr, labelsCSV string) {
+	if selfAddr == "" {
+		selfAddr, _ = os.Hostname()
+	}
+
+	labels := map[string]string{}
+	for _, kv := range strings.Split(labelsCSV, ",") {
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			labels[parts[0]] = parts[1]
+		}
+	}
+
+	var peers []string
+	for _, p := range strings.Split(peersCSV, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" && p != selfAddr {
+			peers = append(peers, p)
+		}
+	}
+
+	pm = &peerManager{
+		selfID:    selfAddr,
+		peers:     peers,
+		labels:    labels,
+		leasePath: filepath.Join(dataDir, "cluster-lease.json"),
+	}
+
+	if len(peers) == 0 {
+		// Single-node deployment: this node is trivially its own leader.
+		pm.isLeader = true
+		pm.leaderAddr = selfAddr
+		return
+	}
+
+	go pm.electionLoop()
+}
+
+func (p *peerManager) electionLoop() {
+	p.tryAcquireOrRenew()
+	ticker := time.NewTicker(leaseRenewEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.tryAcquireOrRenew()
+		if p.amLeader() {
+			p.pushAssignments()
+		}
+	}
+}
+
+// tryAcquireOrRenew implements a simple lease-based bully: whoever holds an
+// unexpired lease stays leader; anyone else takes over once it lapses.
+func (p *peerManager) tryAcquireOrRenew() {
+	now := time.Now()
+	lease, err := p.readLease()
+	if err == nil && lease.HolderID != p.selfID && lease.ExpiresAt.After(now) {
+		p.mu.Lock()
+		p.isLeader = false
+		p.leaderAddr = lease.HolderID
+		p.mu.Unlock()
+		return
+	}
+
+	newLease := leaseRecord{HolderID: p.selfID, ExpiresAt: now.Add(leaseDuration)}
+	if err := p.writeLease(newLease); err != nil {
+		log.Printf("peers: failed to write lease: %v", err)
+		return
+	}
+
+	p.mu.Lock()
+	wasLeader := p.isLeader
+	p.isLeader = true
+	p.leaderAddr = p.selfID
+	p.mu.Unlock()
+
+	if !wasLeader {
+		log.Printf("peers: elected leader (%s)", p.selfID)
+	}
+}
+
+func (p *peerManager) readLease() (leaseRecord, error) {
+	var lease leaseRecord
+	data, err := os.ReadFile(p.leasePath)
+	if err != nil {
+		return lease, err
+	}
+	err = json.Unmarshal(data, &lease)
+	return lease, err
+}
+
+func (p *peerManager) writeLease(lease leaseRecord) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.leasePath, data, 0644)
+}
+
+func (p *peerManager) amLeader() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.isLeader
+}
+
+func (p *peerManager) leader() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.leaderAddr
+}
+
+func (p *peerManager) peersList() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.peers...)
+}
+
+func (p *peerManager) allNodes() []string {
+	return append([]string{p.selfID}, p.peersList()...)
+}
+
+// matchesSelector reports whether this node's labels satisfy a
+// "key=value,key2=value2" selector. An empty selector always matches.
+func (p *peerManager) matchesSelector(selector string) bool {
+	if selector == "" {
+		return true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, kv := range strings.Split(selector, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if p.labels[parts[0]] != parts[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// clusterScoped reports whether job is distributed by the leader via
+// pushAssignments/syncAssigned rather than always run on the node that owns
+// the authoritative store entry.
+func clusterScoped(job *CronJob) bool {
+	return job.ClusterScope != "" && job.ClusterScope != "local"
+}
+
+// shouldRun decides, from this node's perspective, whether it is responsible
+// for running job given the full set of known nodes.
+func (p *peerManager) shouldRun(job *CronJob, nodes []string) bool {
+	switch {
+	case job.ClusterScope == "all-nodes":
+		return p.matchesSelector(job.NodeSelector)
+	case job.ClusterScope == "any-one-node":
+		if !p.matchesSelector(job.NodeSelector) {
+			return false
+		}
+		return pickNode(job.ID, nodes) == p.selfID
+	case strings.HasPrefix(job.ClusterScope, "label:"):
+		return p.matchesSelector(strings.TrimPrefix(job.ClusterScope, "label:"))
+	default:
+		return false
+	}
+}
+
+// pickNode deterministically maps a job to one of nodes, so every node
+// reaches the same "any-one-node" decision without talking to each other.
+func pickNode(jobID string, nodes []string) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(jobID))
+	return nodes[h.Sum32()%uint32(len(nodes))]
+}
+
+// pushAssignments runs on the leader: it computes the cluster-scoped jobs,
+// applies them to itself, and pushes the full set to every peer so each can
+// decide locally which ones it owns.
+func (p *peerManager) pushAssignments() {
+	var scoped []*CronJob
+	for _, job := range store.list() {
+		if job.Enabled && clusterScoped(job) {
+			scoped = append(scoped, job)
+		}
+	}
+
+	nodes := p.allNodes()
+
+	var mine []*CronJob
+	for _, job := range scoped {
+		if p.shouldRun(job, nodes) {
+			mine = append(mine, job)
+		}
+	}
+	runner.syncAssigned(mine)
+
+	payload := assignPayload{Jobs: scoped, Nodes: nodes}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("peers: failed to marshal assignment: %v", err)
+		return
+	}
+	for _, peerAddr := range p.peersList() {
+		p.sendAssignment(peerAddr, data)
+	}
+}
+
+func (p *peerManager) sendAssignment(peerAddr string, data []byte) {
+	url := fmt.Sprintf("http://%s/internal/assign", peerAddr)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sharedSecret != "" {
+		req.Header.Set("X-Cluster-Manager-Secret", sharedSecret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("peers: failed to push assignment to %s: %v", peerAddr, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// proxyToLeader forwards a mutating /cron request to the elected leader,
+// re-attaching the shared secret so the leader's requireRole check accepts it.
+func (p *peerManager) proxyToLeader(w http.ResponseWriter, r *http.Request) {
+	leaderAddr := p.leader()
+	if leaderAddr == "" || leaderAddr == p.selfID {
+		http.Error(w, "no leader elected", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	url := fmt.Sprintf("http://%s%s", leaderAddr, r.URL.RequestURI())
+	proxyReq, err := http.NewRequest(r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "failed to build proxy request", http.StatusInternalServerError)
+		return
+	}
+	proxyReq.Header = r.Header.Clone()
+	if sharedSecret != "" {
+		proxyReq.Header.Set("X-Cluster-Manager-Secret", sharedSecret)
+	}
+
+	resp, err := http.DefaultClient.Do(proxyReq)
+	if err != nil {
+		http.Error(w, "leader unreachable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// forwardToLeaderIfNeeded makes next a no-op passthrough on the leader, and a
+// proxy to the leader on every other node.
+func forwardToLeaderIfNeeded(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pm == nil || pm.amLeader() {
+			next(w, r)
+			return
+		}
+		pm.proxyToLeader(w, r)
+	}
+}
+
+// handleInternalAssign receives the leader's view of cluster-scoped jobs and
+// keeps only the ones this node is responsible for running.
+func handleInternalAssign(w http.ResponseWriter, r *http.Request) {
+	if sharedSecret != "" && r.Header.Get("X-Cluster-Manager-Secret") != sharedSecret {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload assignPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var mine []*CronJob
+	for _, job := range payload.Jobs {
+		if pm.shouldRun(job, payload.Nodes) {
+			mine = append(mine, job)
+		}
+	}
+	runner.syncAssigned(mine)
+
+	writeJSON(w, map[string]string{"status": "ok"})
+}
@@ -1,24 +1,138 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/creack/pty"
 	"golang.org/x/net/websocket"
 )
 
-func runTerminalSession(ws *websocket.Conn, cmd *exec.Cmd) {
-	// Start the command with a pseudo-terminal
+// clientFrame is a typed message sent by the browser over /terminal,
+// replacing the old hand-rolled substring scan for resize events.
+type clientFrame struct {
+	Type string `json:"type"` // stdin, resize, signal, env, upload, download
+	Data string `json:"data,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+	Name string `json:"name,omitempty"` // signal name, e.g. SIGINT
+	Key  string `json:"key,omitempty"`  // env var name
+	Val  string `json:"val,omitempty"`  // env var value
+	Path string `json:"path,omitempty"` // upload/download target path
+	Mode string `json:"mode,omitempty"` // upload file mode, octal, e.g. "0644"
+}
+
+// serverFrame is a typed message sent back to the browser.
+type serverFrame struct {
+	Type string `json:"type"` // stdout, exit, file-chunk
+	Data string `json:"data,omitempty"`
+	Code int    `json:"code,omitempty"`
+	Path string `json:"path,omitempty"`
+	Done bool   `json:"done,omitempty"`
+}
+
+const downloadChunkSize = 32 * 1024
+
+var terminalSignals = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// negotiatedProtocolVersion reads the "term.vN" entry from the client's
+// requested subprotocols, defaulting to "term.v1" for older clients that
+// don't send one. This keeps room to version the frame protocol later.
+func negotiatedProtocolVersion(ws *websocket.Conn) string {
+	proto := ws.Request().Header.Get("Sec-WebSocket-Protocol")
+	for _, p := range strings.Split(proto, ",") {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "term.v") {
+			return p
+		}
+	}
+	return "term.v1"
+}
+
+// terminalResult summarizes a finished terminal session for the audit log.
+type terminalResult struct {
+	ExitCode int
+	BytesIn  int64
+	BytesOut int64
+}
+
+// wsWriter serializes writes to a websocket.Conn. golang.org/x/net/websocket's
+// Conn.Write is not safe for concurrent callers (each call frames the message
+// with multiple underlying writes), and the pty-read goroutine and the
+// stdin-dispatch goroutine (which can trigger handleDownload) both send
+// frames, so every write must go through this.
+type wsWriter struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (w *wsWriter) sendFrame(frame serverFrame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.ws.Write(data)
+	return err
+}
+
+func runTerminalSession(ws *websocket.Conn, cmd *exec.Cmd) terminalResult {
+	out := &wsWriter{ws: ws}
+	var bytesIn, bytesOut atomic.Int64
+
+	// "env" frames only make sense before the shell starts, so collect any
+	// that arrive first and stash the first non-env frame to replay once the
+	// pty is up.
+	var extraEnv []string
+	var pending *clientFrame
+	for {
+		frame, n, err := readClientFrame(ws)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("ws read error: %v", err)
+			}
+			ws.Close()
+			return terminalResult{ExitCode: -1}
+		}
+		if frame == nil {
+			continue // malformed frame, already logged
+		}
+		bytesIn.Add(int64(n))
+		if frame.Type != "env" {
+			pending = frame
+			break
+		}
+		if frame.Key != "" {
+			extraEnv = append(extraEnv, frame.Key+"="+frame.Val)
+		}
+	}
+	cmd.Env = append(cmd.Env, extraEnv...)
+
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
 		log.Printf("failed to start pty: %v", err)
-		ws.Write([]byte("Failed to start terminal: " + err.Error() + "\r\n"))
+		out.sendFrame(serverFrame{Type: "exit", Code: -1})
 		ws.Close()
-		return
+		return terminalResult{ExitCode: -1}
 	}
 	defer ptmx.Close()
 
@@ -28,118 +142,192 @@ func runTerminalSession(ws *websocket.Conn, cmd *exec.Cmd) {
 		Cols: 80,
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	var wg sync.WaitGroup
-	done := make(chan struct{})
 
-	// Copy pty output to websocket
+	// Copy pty output to the websocket as stdout frames
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer cancel()
 		buf := make([]byte, 4096)
 		for {
-			select {
-			case <-done:
-				return
-			default:
-				n, err := ptmx.Read(buf)
-				if err != nil {
-					if err != io.EOF {
-						log.Printf("pty read error: %v", err)
-					}
-					return
-				}
-				if n > 0 {
-					if _, err := ws.Write(buf[:n]); err != nil {
-						log.Printf("ws write error: %v", err)
-						return
-					}
+			n, err := ptmx.Read(buf)
+			if err != nil {
+				if err != io.EOF {
+					log.Printf("pty read error: %v", err)
 				}
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			bytesOut.Add(int64(n))
+			if err := out.sendFrame(serverFrame{Type: "stdout", Data: base64.StdEncoding.EncodeToString(buf[:n])}); err != nil {
+				log.Printf("ws write error: %v", err)
+				return
 			}
 		}
 	}()
 
-	// Copy websocket input to pty
+	// Dispatch the frame that ended the initial env-collection loop, then
+	// keep reading and dispatching frames for the rest of the session.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		buf := make([]byte, 4096)
+		defer cancel()
+
+		dispatchFrame(*pending, ptmx, cmd, out)
 		for {
-			n, err := ws.Read(buf)
+			frame, n, err := readClientFrame(ws)
 			if err != nil {
 				if err != io.EOF {
 					log.Printf("ws read error: %v", err)
 				}
-				close(done)
-				cmd.Process.Signal(os.Interrupt)
 				return
 			}
-			if n > 0 {
-				// Check for resize message (JSON format)
-				if buf[0] == '{' {
-					handleResize(ptmx, buf[:n])
-					continue
-				}
-				if _, err := ptmx.Write(buf[:n]); err != nil {
-					log.Printf("pty write error: %v", err)
-					return
-				}
+			if frame == nil {
+				continue
 			}
+			bytesIn.Add(int64(n))
+			dispatchFrame(*frame, ptmx, cmd, out)
 		}
 	}()
 
-	// Wait for command to finish
+	<-ctx.Done()
+	cmd.Process.Signal(os.Interrupt)
+
+	exitCode := 0
 	if err := cmd.Wait(); err != nil {
 		log.Printf("command finished with error: %v", err)
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+		}
 	}
 
-	// Cleanup
-	close(done)
+	// Send the exit frame and close ws before wg.Wait(): the stdin-dispatch
+	// goroutine is typically blocked in a read on ws, which only a closed
+	// conn (not ctx) will unblock. Waiting on wg first — e.g. when the shell
+	// exits on its own rather than the client disconnecting — would hang
+	// here forever with the client never told the session ended.
+	out.sendFrame(serverFrame{Type: "exit", Code: exitCode})
 	ws.Close()
 	wg.Wait()
+
+	return terminalResult{ExitCode: exitCode, BytesIn: bytesIn.Load(), BytesOut: bytesOut.Load()}
 }
 
-func handleResize(ptmx *os.File, data []byte) {
-	// Simple JSON parsing for resize messages: {"cols":80,"rows":24}
-	var cols, rows uint16 = 80, 24
-
-	// Very basic parsing - in production use json.Unmarshal
-	str := string(data)
-	if len(str) > 10 {
-		// Parse cols
-		for i := 0; i < len(str)-5; i++ {
-			if str[i:i+6] == "\"cols\"" {
-				j := i + 7
-				for j < len(str) && str[j] == ' ' || str[j] == ':' {
-					j++
-				}
-				num := uint16(0)
-				for j < len(str) && str[j] >= '0' && str[j] <= '9' {
-					num = num*10 + uint16(str[j]-'0')
-					j++
-				}
-				if num > 0 {
-					cols = num
-				}
-			}
-			if str[i:i+6] == "\"rows\"" {
-				j := i + 7
-				for j < len(str) && str[j] == ' ' || str[j] == ':' {
-					j++
-				}
-				num := uint16(0)
-				for j < len(str) && str[j] >= '0' && str[j] <= '9' {
-					num = num*10 + uint16(str[j]-'0')
-					j++
-				}
-				if num > 0 {
-					rows = num
-				}
-			}
+// readClientFrame reads one websocket message and decodes it as a
+// clientFrame. A nil frame with a nil error means the message was malformed
+// and has already been logged; callers should just read again.
+func readClientFrame(ws *websocket.Conn) (*clientFrame, int, error) {
+	buf := make([]byte, 65536)
+	n, err := ws.Read(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var frame clientFrame
+	if err := json.Unmarshal(buf[:n], &frame); err != nil {
+		log.Printf("invalid terminal frame: %v", err)
+		return nil, n, nil
+	}
+	return &frame, n, nil
+}
+
+func dispatchFrame(frame clientFrame, ptmx *os.File, cmd *exec.Cmd, out *wsWriter) {
+	switch frame.Type {
+	case "stdin":
+		data, err := base64.StdEncoding.DecodeString(frame.Data)
+		if err != nil {
+			log.Printf("invalid stdin frame: %v", err)
+			return
 		}
+		if _, err := ptmx.Write(data); err != nil {
+			log.Printf("pty write error: %v", err)
+		}
+	case "resize":
+		if frame.Cols > 0 && frame.Rows > 0 {
+			pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(frame.Cols), Rows: uint16(frame.Rows)})
+		}
+	case "signal":
+		if sig, ok := terminalSignals[frame.Name]; ok {
+			cmd.Process.Signal(sig)
+		}
+	case "upload":
+		handleUpload(frame)
+	case "download":
+		handleDownload(out, frame)
+	case "env":
+		// Only honored during the pre-start collection loop; ignore once running.
+	default:
+		log.Printf("unknown terminal frame type: %q", frame.Type)
 	}
+}
 
-	pty.Setsize(ptmx, &pty.Winsize{
-		Rows: rows,
-		Cols: cols,
-	})
+// hostFilePath resolves a client-supplied upload/download path against
+// hostRoot, the same filesystem namespace the chrooted shell runs in, and
+// rejects any path that would traverse outside of it.
+func hostFilePath(path string) (string, error) {
+	full := filepath.Join(hostRoot, filepath.Clean("/"+path))
+	if full != hostRoot && !strings.HasPrefix(full, hostRoot+string(os.PathSeparator)) {
+		return "", fmt.Errorf("path %q escapes host root", path)
+	}
+	return full, nil
+}
+
+func handleUpload(frame clientFrame) {
+	path, err := hostFilePath(frame.Path)
+	if err != nil {
+		log.Printf("upload rejected: %v", err)
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(frame.Data)
+	if err != nil {
+		log.Printf("invalid upload frame: %v", err)
+		return
+	}
+
+	mode := os.FileMode(0644)
+	if frame.Mode != "" {
+		if m, err := strconv.ParseUint(frame.Mode, 8, 32); err == nil {
+			mode = os.FileMode(m)
+		}
+	}
+
+	if err := os.WriteFile(path, data, mode); err != nil {
+		log.Printf("upload to %s failed: %v", path, err)
+	}
+}
+
+func handleDownload(out *wsWriter, frame clientFrame) {
+	path, err := hostFilePath(frame.Path)
+	if err != nil {
+		log.Printf("download rejected: %v", err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("download of %s failed: %v", path, err)
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			out.sendFrame(serverFrame{Type: "file-chunk", Path: frame.Path, Data: base64.StdEncoding.EncodeToString(buf[:n])})
+		}
+		if err != nil {
+			break
+		}
+	}
+	out.sendFrame(serverFrame{Type: "file-chunk", Path: frame.Path, Done: true})
 }
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,6 +17,22 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+var (
+	nodeCPUPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "node_cpu_percent",
+		Help: "CPU usage as a percentage of a node's capacity.",
+	}, []string{"node"})
+
+	nodeMemoryPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "node_memory_percent",
+		Help: "Memory usage as a percentage of a node's capacity.",
+	}, []string{"node"})
+)
+
+func init() {
+	prometheus.MustRegister(nodeCPUPercent, nodeMemoryPercent)
+}
+
 type NodeMetrics struct {
 	Name           string  `json:"name"`
 	CPUUsage       string  `json:"cpu_usage"`
@@ -24,6 +42,9 @@ type NodeMetrics struct {
 	CPUPercent     float64 `json:"cpu_percent"`
 	MemoryPercent  float64 `json:"memory_percent"`
 	Conditions     []NodeCondition `json:"conditions,omitempty"`
+	PodsRunning    int     `json:"pods_running"`
+	PodsPending    int     `json:"pods_pending"`
+	Taints         []NodeTaint `json:"taints,omitempty"`
 }
 
 type NodeCondition struct {
@@ -31,6 +52,15 @@ type NodeCondition struct {
 	Status string `json:"status"`
 }
 
+// NodeTaint mirrors the scheduling-relevant fields of a corev1.Taint, surfaced
+// so callers can see at a glance why a node is under pressure (e.g. the
+// node-pressure taints kubelet applies automatically).
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
 type ClusterInfo struct {
 	Timestamp time.Time     `json:"timestamp"`
 	Nodes     []NodeMetrics `json:"nodes"`
@@ -73,6 +103,8 @@ func main() {
 		w.Write([]byte("ok"))
 	})
 
+	http.Handle("/metrics", promhttp.Handler())
+
 	log.Printf("Cluster monitor listening on %s", *addr)
 	log.Fatal(http.ListenAndServe(*addr, nil))
 }
@@ -98,6 +130,24 @@ func handleClusterInfo(w http.ResponseWriter, r *http.Request, clientset *kubern
 		return
 	}
 
+	// Get pods across all namespaces so we can count running/pending per node
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		http.Error(w, "Failed to get pods: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	podsRunningByNode := make(map[string]int)
+	podsPendingByNode := make(map[string]int)
+	for _, pod := range pods.Items {
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			podsRunningByNode[pod.Spec.NodeName]++
+		case corev1.PodPending:
+			podsPendingByNode[pod.Spec.NodeName]++
+		}
+	}
+
 	// Parse metrics response
 	var metricsResponse metricsNodeList
 	if err := json.Unmarshal(metricsData, &metricsResponse); err != nil {
@@ -147,6 +197,11 @@ func handleClusterInfo(w http.ResponseWriter, r *http.Request, clientset *kubern
 			}
 		}
 
+		var taints []NodeTaint
+		for _, t := range node.Spec.Taints {
+			taints = append(taints, NodeTaint{Key: t.Key, Value: t.Value, Effect: string(t.Effect)})
+		}
+
 		nodeMetrics = append(nodeMetrics, NodeMetrics{
 			Name:           item.Metadata.Name,
 			CPUUsage:       item.Usage.CPU,
@@ -156,7 +211,13 @@ func handleClusterInfo(w http.ResponseWriter, r *http.Request, clientset *kubern
 			CPUPercent:     cpuPercent,
 			MemoryPercent:  memPercent,
 			Conditions:     conditions,
+			PodsRunning:    podsRunningByNode[item.Metadata.Name],
+			PodsPending:    podsPendingByNode[item.Metadata.Name],
+			Taints:         taints,
 		})
+
+		nodeCPUPercent.WithLabelValues(item.Metadata.Name).Set(cpuPercent)
+		nodeMemoryPercent.WithLabelValues(item.Metadata.Name).Set(memPercent)
 	}
 
 	response := ClusterInfo{